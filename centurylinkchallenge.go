@@ -24,15 +24,20 @@ In this project, two web API endpoints are necessary. They are:
 Assume these endpoints will be under a continuous load being called for thousands of individual servers every minute.
 */
 
-// The context of servers that have pulsed with updates
+// The context of servers that have pulsed with updates. Persistence is
+// delegated entirely to store, so Context itself holds no server state.
 type Context struct {
-	servers map[string]*server
+	store   Store
+	Metrics Metrics
 }
 
-// The server object
-type server struct {
-	Name       string
-	Statistics []*pulse
+// NewContext creates a Context backed by store. A nil store defaults to a
+// non-durable in-memory Store, matching the original behavior.
+func NewContext(store Store) *Context {
+	if store == nil {
+		store = newMemoryStore()
+	}
+	return &Context{store: store}
 }
 
 // Allows capturing other information easily without changing the structure
@@ -43,25 +48,24 @@ type pulse struct {
 	Time time.Time      `json:"time"`
 }
 
-// Utilized when finding averages within a timeframe
-type average struct {
-	count  int
-	memsum float64
-	cpusum float64
-}
-
 // StartTheChallenge makes little children cry,
 // don't worry though,
 // i'm fly.
 func StartTheChallenge() error {
-	context := &Context{
-		servers: make(map[string]*server),
+	store, err := newStoreFromEnv()
+	if err != nil {
+		return err
 	}
+	defer store.Close()
+	context := NewContext(store)
 	log.Printf("Welcome to the CenturyLink Challenge, where all not-quite-snmp needs are fulfilled.")
 	log.Printf("The time is: %v (this is RF3339)", time.Now().Format(time.RFC3339))
 	router := mux.NewRouter()
 	router.HandleFunc("/update", Handler{context, update}.ServeHTTP)
 	router.HandleFunc("/get/{server}", Handler{context, get}.ServeHTTP)
+	router.HandleFunc("/write", Handler{context, write}.ServeHTTP)
+	router.HandleFunc("/metrics", Handler{context, metrics}.ServeHTTP)
+	router.HandleFunc("/stats.json", Handler{context, statsJSON}.ServeHTTP)
 	return http.ListenAndServe(":8080", router)
 }
 
@@ -71,8 +75,11 @@ type Handler struct {
 	H func(*Context, http.ResponseWriter, *http.Request) (int, error)
 }
 
-// ServeHTTP function that utilizes contextual data
+// ServeHTTP function that utilizes contextual data. Every request is timed
+// and its status code recorded against Context.Metrics, regardless of which
+// handler served it.
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	status, err := h.H(h.Context, w, r)
 	if err != nil {
 		switch status {
@@ -81,109 +88,83 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case http.StatusInternalServerError:
 			http.Error(w, http.StatusText(status), status)
 		default:
-			http.Error(w, http.StatusText(status), status)
+			http.Error(w, err.Error(), status)
 		}
 	}
+	h.Metrics.observe(routeLabel(r), status, time.Since(start))
+}
+
+// routeLabel returns the registered route template (e.g. "/get/{server}")
+// rather than the literal request path, so a path parameter like a server
+// name doesn't become its own Prometheus series. It falls back to the raw
+// path if the request wasn't routed through mux, as in direct handler tests.
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
 }
 
 // update receives an server pulse
 func update(c *Context, w http.ResponseWriter, r *http.Request) (int, error) {
+	c.Metrics.incUpdatesReceived()
 	decoder := json.NewDecoder(r.Body)
 	stat := &pulse{}
 	err := decoder.Decode(stat)
 	if err != nil {
 		log.Println(err)
+		c.Metrics.incDecodeErrors()
+		c.Metrics.incUpdatesDropped()
 		return 500, err
 	}
 	log.Printf("Received an update for server %v", stat.Name)
 	return c.upsert(stat)
 }
 
+// statsResponse is the payload returned by /get/{server}: the rolling
+// per-minute and per-hour histograms, oldest bucket first.
+type statsResponse struct {
+	Server string                     `json:"server"`
+	Minute [minuteBuckets]BucketStats `json:"minute"`
+	Hour   [hourBuckets]BucketStats   `json:"hour"`
+}
+
 // get finds server information if available and returns an appropriate response
 func get(c *Context, w http.ResponseWriter, r *http.Request) (int, error) {
+	c.Metrics.incGetsServed()
 	vars := mux.Vars(r)
-	server := vars["server"]
-	if _, exists := c.servers[server]; !exists {
-		return 500, errors.New(fmt.Sprintf("No server information found for '%v'.", server))
+	name := vars["server"]
+	stats, exists := c.store.LoadServer(name)
+	if !exists {
+		return 500, errors.New(fmt.Sprintf("No server information found for '%v'.", name))
 	}
-	if len(c.servers[server].Statistics) == 0 {
-		fmt.Fprintf(w, "No update information for server %v found.", server)
+	if stats.empty() {
+		fmt.Fprintf(w, "No update information for server %v found.", name)
 		return 200, nil
 	}
-	mem60, cpu60 := c.servers[server].average(time.Duration(time.Minute * 60), time.Duration(time.Minute * 1))
-	mem24, cpu24 := c.servers[server].average(time.Duration(time.Hour * 24), time.Duration(time.Hour * 1))
-
-	fmt.Fprintf(w, fmt.Sprintf("Averages over the Last Hour: Memory: %v, CPU: %v. Last 24 Hours: Memory: %v, CPU: %v",
-		mem60, cpu60, mem24, cpu24))
-	log.Printf("Returning request for information for server %v.", server)
+	now := time.Now()
+	resp := statsResponse{
+		Server: name,
+		Minute: stats.minuteSnapshot(now),
+		Hour:   stats.hourSnapshot(now),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Println(err)
+		return 500, err
+	}
+	log.Printf("Returning request for information for server %v.", name)
 	return 200, nil
 }
 
-// upsert creates a statistic if needed and inserts the relevant data
+// upsert persists the pulse through the Context's Store, which creates the
+// server's rolling statistics if needed and folds the pulse into its
+// minute/hour histograms in O(1).
 func (c *Context) upsert(s *pulse) (int, error) {
-	if _, exists := c.servers[s.Name]; !exists {
-		c.servers[s.Name] = &server{
-			Name: s.Name,
-			Statistics: make([]*pulse, 0),
-		}
+	if err := c.store.Append(s); err != nil {
+		return 500, err
 	}
-	c.servers[s.Name].Statistics = append(c.servers[s.Name].Statistics, s)
 	return 200, nil
 }
-
-// average creates an average every duration specified by rate, up to length
-func (s *server) average(length, rate time.Duration) ([]float64, []float64) {
-	a := &average{
-		memsum: float64(0.0),
-		cpusum: float64(0.0),
-		count: 0,
-	}
-	cpuaverages := make([]float64, 0) // create slices
-	memaverages := make([]float64, 0) // create slices
-
-	calculating := true
-	start := time.Now()
-	durations := 1
-	for i := len(s.Statistics) - 1; calculating; i-- {
-		// if the current pulse was sampled before the time range request, end calculating
-		if i < 0 || s.Statistics[i].Time.Before(start.Add(-1 * length)) {
-			if (a.cpusum > 0) {
-				cpuaverages = append(cpuaverages, a.cpusum / float64(a.count))
-				memaverages = append(memaverages, a.memsum / float64(a.count))
-			}
-			calculating = false
-			continue
-		}
-		// if the current pulse is within the current duration, add it
-		if s.Statistics[i].Time.After(start.Add(time.Duration(float64(-1.0 * durations) * rate.Minutes()) * time.Minute)) {
-			a.count++
-			a.cpusum += s.Statistics[i].Cpu
-			a.memsum += s.Statistics[i].Mem
-			continue
-		}
-		// if the current pulse is before the current duration, calculate current average and reset
-		for s.Statistics[i].Time.Before(start.Add(time.Duration(float64(-1 * durations) * rate.Minutes()) * time.Minute)) {
-			durations++
-			cpuaverages, memaverages, a = appendAndReset(cpuaverages, memaverages, a)
-		}
-		// If this is the end of the pulses, clean up and move on
-		if i == 0 {
-			appendAndReset(cpuaverages, memaverages, a)
-		}
-	}
-	return memaverages, cpuaverages
-}
-
-// appendAndReset adds the current statistics to the running list and resets the average
-func appendAndReset(cpuaverages, memaverages []float64, a *average) ([]float64, []float64, *average) {
-	if (a.cpusum > 0) {
-		cpuaverages = append(cpuaverages, a.cpusum / float64(a.count))
-		memaverages = append(memaverages, a.memsum / float64(a.count))
-		a = &average{
-			cpusum: float64(0),
-			memsum: float64(0),
-			count: 0,
-		}
-	}
-	return cpuaverages, memaverages, a
-}
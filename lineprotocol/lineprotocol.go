@@ -0,0 +1,276 @@
+// Package lineprotocol parses InfluxDB line protocol payloads, the format
+// emitted by Telegraf, collectd, and vector agents:
+//
+//	measurement,tag=val field=1.0,field2=2.0 1465839830100400200
+//
+// so they can be translated into this service's own pulse records without a
+// custom JSON shim in front of every agent.
+package lineprotocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Precision identifies the unit of a line's trailing timestamp.
+type Precision string
+
+const (
+	Nanosecond  Precision = "ns"
+	Microsecond Precision = "us"
+	Millisecond Precision = "ms"
+	Second      Precision = "s"
+)
+
+// Point is a single decoded line protocol line.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+}
+
+// ParseError identifies the 1-based line number a batch failed to parse on.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Parse decodes a batch of newline-separated line protocol lines. Blank lines
+// and lines starting with '#' (comments) are skipped. precision is applied to
+// every line's timestamp; an empty precision defaults to Nanosecond, matching
+// the InfluxDB write API.
+func Parse(data []byte, precision Precision) ([]Point, error) {
+	if precision == "" {
+		precision = Nanosecond
+	}
+	lines := strings.Split(string(data), "\n")
+	points := make([]Point, 0, len(lines))
+	for i, raw := range lines {
+		line := strings.TrimRight(raw, "\r")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseLine(line, precision)
+		if err != nil {
+			return nil, &ParseError{Line: i + 1, Err: err}
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// parseLine decodes a single "measurement,tag=val,... field=val,... [ts]" line.
+func parseLine(line string, precision Precision) (Point, error) {
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return Point{}, fmt.Errorf("expected \"measurement[,tags] fields [timestamp]\", got %d section(s)", len(sections))
+	}
+
+	measurement, tags, err := parseKeys(sections[0])
+	if err != nil {
+		return Point{}, err
+	}
+	if measurement == "" {
+		return Point{}, fmt.Errorf("missing measurement name")
+	}
+
+	fields, err := parseFields(sections[1])
+	if err != nil {
+		return Point{}, err
+	}
+	if len(fields) == 0 {
+		return Point{}, fmt.Errorf("missing field set")
+	}
+
+	var ts string
+	if len(sections) == 3 {
+		ts = sections[2]
+	}
+	t, err := parseTimestamp(ts, precision)
+	if err != nil {
+		return Point{}, err
+	}
+
+	return Point{Measurement: measurement, Tags: tags, Fields: fields, Time: t}, nil
+}
+
+// parseKeys splits "measurement,tag1=val1,tag2=val2" into the measurement
+// name and its tag set.
+func parseKeys(section string) (string, map[string]string, error) {
+	parts := splitUnescaped(section, ',', false)
+	measurement := unescape(parts[0])
+	if len(parts) == 1 {
+		return measurement, nil, nil
+	}
+	tags := make(map[string]string, len(parts)-1)
+	for _, kv := range parts[1:] {
+		k, v, ok := splitUnescapedKV(kv)
+		if !ok {
+			return "", nil, fmt.Errorf("invalid tag %q: expected key=value", kv)
+		}
+		tags[k] = v
+	}
+	return measurement, tags, nil
+}
+
+// parseFields splits "cpu=1.0,host=\"web-1\"" into a field set.
+func parseFields(section string) (map[string]interface{}, error) {
+	parts := splitUnescaped(section, ',', true)
+	fields := make(map[string]interface{}, len(parts))
+	for _, kv := range parts {
+		idx := strings.IndexByte(kv, '=')
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid field %q: expected key=value", kv)
+		}
+		key := unescape(kv[:idx])
+		value, err := parseFieldValue(kv[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", key, err)
+		}
+		fields[key] = value
+	}
+	return fields, nil
+}
+
+// parseFieldValue decodes a field's value as a quoted string, boolean,
+// integer (trailing "i"), or float, per the line protocol grammar.
+func parseFieldValue(raw string) (interface{}, error) {
+	if strings.HasPrefix(raw, `"`) {
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return nil, fmt.Errorf("unterminated quoted string %q", raw)
+		}
+		inner := raw[1 : len(raw)-1]
+		inner = strings.ReplaceAll(inner, `\"`, `"`)
+		inner = strings.ReplaceAll(inner, `\\`, `\`)
+		return inner, nil
+	}
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	if strings.HasSuffix(raw, "i") {
+		n, err := strconv.ParseInt(strings.TrimSuffix(raw, "i"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q: %v", raw, err)
+		}
+		return n, nil
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q: %v", raw, err)
+	}
+	return f, nil
+}
+
+// parseTimestamp converts a raw integer timestamp using precision. An empty
+// timestamp yields the zero time, leaving it to the caller to stamp "now".
+func parseTimestamp(raw string, precision Precision) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %v", raw, err)
+	}
+	switch precision {
+	case Nanosecond:
+		return time.Unix(0, n), nil
+	case Microsecond:
+		return time.Unix(0, n*int64(time.Microsecond)), nil
+	case Millisecond:
+		return time.Unix(0, n*int64(time.Millisecond)), nil
+	case Second:
+		return time.Unix(n, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown precision %q", precision)
+	}
+}
+
+// splitUnescaped splits s on unescaped occurrences of sep. Only a backslash
+// immediately preceding sep (or, when respectQuotes is set, a double quote)
+// is consumed here; any other backslash is left untouched so a later split
+// stage (e.g. splitting tags on ',' after already splitting on ' ') still
+// sees the escape it's responsible for. When respectQuotes is true,
+// occurrences of sep inside a double-quoted run (as used by string field
+// values) are treated as literal rather than as separators.
+func splitUnescaped(s string, sep byte, respectQuotes bool) []string {
+	var out []string
+	var buf strings.Builder
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			if next == sep || next == '\\' || (respectQuotes && next == '"') {
+				buf.WriteByte(next)
+				i++
+				continue
+			}
+			buf.WriteByte(c)
+			continue
+		}
+		if respectQuotes && c == '"' {
+			inQuotes = !inQuotes
+			buf.WriteByte(c)
+			continue
+		}
+		if c == sep && !inQuotes {
+			out = append(out, buf.String())
+			buf.Reset()
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	out = append(out, buf.String())
+	return out
+}
+
+// splitUnescapedKV splits a "key=value" token on its first unescaped '=',
+// unescaping both sides.
+func splitUnescapedKV(s string) (key, value string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '\\' && i+1 < len(s) {
+			i++
+			continue
+		}
+		if c == '=' {
+			return unescape(s[:i]), unescape(s[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+// unescape removes the backslash from any remaining backslash-escaped
+// character once all split stages have run.
+func unescape(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	var buf strings.Builder
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !escaped && c == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
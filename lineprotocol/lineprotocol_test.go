@@ -0,0 +1,128 @@
+package lineprotocol
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBasicLine(t *testing.T) {
+	points, err := Parse([]byte("cpu,host=web-1 value=64.2 1465839830100400200"), Nanosecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point, got %d", len(points))
+	}
+	p := points[0]
+	if p.Measurement != "cpu" {
+		t.Fatalf("expected measurement %q, got %q", "cpu", p.Measurement)
+	}
+	if p.Tags["host"] != "web-1" {
+		t.Fatalf("expected tag host=web-1, got %v", p.Tags)
+	}
+	if p.Fields["value"] != 64.2 {
+		t.Fatalf("expected field value=64.2, got %v", p.Fields["value"])
+	}
+	if !p.Time.Equal(time.Unix(0, 1465839830100400200)) {
+		t.Fatalf("unexpected timestamp: %v", p.Time)
+	}
+}
+
+func TestParseEscapedCommaSpaceEquals(t *testing.T) {
+	points, err := Parse([]byte(`my\ measurement,tag\,key=val\ with\ space cpu=1.0`), Nanosecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := points[0]
+	if p.Measurement != "my measurement" {
+		t.Fatalf("expected unescaped measurement %q, got %q", "my measurement", p.Measurement)
+	}
+	if p.Tags["tag,key"] != "val with space" {
+		t.Fatalf("expected unescaped tag, got %v", p.Tags)
+	}
+}
+
+func TestParseQuotedStringField(t *testing.T) {
+	points, err := Parse([]byte(`event,host=web-1 message="deploy, v2 \"hotfix\"" 100`), Nanosecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := points[0].Fields["message"]
+	want := `deploy, v2 "hotfix"`
+	if got != want {
+		t.Fatalf("expected message %q, got %q", want, got)
+	}
+}
+
+func TestParseMultiLineBatch(t *testing.T) {
+	batch := strings.Join([]string{
+		"cpu,host=web-1 cpu=10.0,mem=20.0 100",
+		"cpu,host=web-2 cpu=30.0,mem=40.0 200",
+	}, "\n")
+	points, err := Parse([]byte(batch), Nanosecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if points[0].Tags["host"] != "web-1" || points[1].Tags["host"] != "web-2" {
+		t.Fatalf("points out of order or mismatched: %+v", points)
+	}
+}
+
+func TestParseBlankLinesAndCommentsAreSkipped(t *testing.T) {
+	batch := "cpu,host=web-1 cpu=1.0 100\n\n# a comment\ncpu,host=web-2 cpu=2.0 200\n"
+	points, err := Parse([]byte(batch), Nanosecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+}
+
+func TestParsePrecisions(t *testing.T) {
+	cases := []struct {
+		precision Precision
+		raw       string
+		want      time.Time
+	}{
+		{Second, "100", time.Unix(100, 0)},
+		{Millisecond, "100", time.Unix(0, 100*int64(time.Millisecond))},
+		{Microsecond, "100", time.Unix(0, 100*int64(time.Microsecond))},
+		{Nanosecond, "100", time.Unix(0, 100)},
+	}
+	for _, c := range cases {
+		points, err := Parse([]byte("cpu,host=web-1 cpu=1.0 "+c.raw), c.precision)
+		if err != nil {
+			t.Fatalf("precision %v: unexpected error: %v", c.precision, err)
+		}
+		if !points[0].Time.Equal(c.want) {
+			t.Fatalf("precision %v: expected %v, got %v", c.precision, c.want, points[0].Time)
+		}
+	}
+}
+
+func TestParseMalformedLineIdentifiesLineNumber(t *testing.T) {
+	batch := "cpu,host=web-1 cpu=1.0 100\ncpu,host=web-2 cpu=notanumber 200"
+	_, err := Parse([]byte(batch), Nanosecond)
+	if err == nil {
+		t.Fatal("expected an error for malformed field value")
+	}
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %T", err)
+	}
+	if perr.Line != 2 {
+		t.Fatalf("expected error on line 2, got line %d", perr.Line)
+	}
+}
+
+func TestParseMissingFieldSet(t *testing.T) {
+	_, err := Parse([]byte("cpu,host=web-1 100"), Nanosecond)
+	if err == nil {
+		t.Fatal("expected an error for a line with no field set")
+	}
+}
@@ -0,0 +1,193 @@
+package centurylinkchallenge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the Prometheus-conventional histogram boundaries (in
+// seconds) used for centurylinkchallenge_request_duration_seconds.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// routeStatus keys the per-path, per-status-code request counter.
+type routeStatus struct {
+	path   string
+	status int
+}
+
+// Metrics holds the process-level counters and per-path latency histogram
+// that back /metrics and /stats.json. The zero value is ready to use.
+type Metrics struct {
+	updatesReceived uint64
+	updatesDropped  uint64
+	getsServed      uint64
+	decodeErrors    uint64
+
+	mu            sync.Mutex
+	statuses      map[routeStatus]uint64
+	latencyCounts map[string][]uint64
+	latencySum    map[string]float64
+	latencyTotal  map[string]uint64
+}
+
+func (m *Metrics) incUpdatesReceived() { atomic.AddUint64(&m.updatesReceived, 1) }
+func (m *Metrics) incUpdatesDropped()  { atomic.AddUint64(&m.updatesDropped, 1) }
+func (m *Metrics) incGetsServed()      { atomic.AddUint64(&m.getsServed, 1) }
+func (m *Metrics) incDecodeErrors()    { atomic.AddUint64(&m.decodeErrors, 1) }
+
+// observe records a completed request's status code and latency, keyed by
+// path, so every route handled through Handler is instrumented without the
+// handlers themselves needing to know about metrics.
+func (m *Metrics) observe(path string, status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.statuses == nil {
+		m.statuses = make(map[routeStatus]uint64)
+	}
+	m.statuses[routeStatus{path, status}]++
+
+	if m.latencyCounts == nil {
+		m.latencyCounts = make(map[string][]uint64)
+		m.latencySum = make(map[string]float64)
+		m.latencyTotal = make(map[string]uint64)
+	}
+	buckets, ok := m.latencyCounts[path]
+	if !ok {
+		buckets = make([]uint64, len(latencyBuckets))
+		m.latencyCounts[path] = buckets
+	}
+	seconds := d.Seconds()
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			buckets[i]++
+		}
+	}
+	m.latencySum[path] += seconds
+	m.latencyTotal[path]++
+}
+
+// writeProm renders every collected metric in Prometheus text exposition
+// format.
+func (m *Metrics) writeProm(w io.Writer, servers []ServerSnapshot) {
+	fmt.Fprintln(w, "# HELP server_cpu_load Most recently reported CPU load for a server.")
+	fmt.Fprintln(w, "# TYPE server_cpu_load gauge")
+	for _, s := range servers {
+		fmt.Fprintf(w, "server_cpu_load{server=%q} %v\n", s.Name, s.CpuLoad)
+	}
+	fmt.Fprintln(w, "# HELP server_mem_load Most recently reported memory load for a server.")
+	fmt.Fprintln(w, "# TYPE server_mem_load gauge")
+	for _, s := range servers {
+		fmt.Fprintf(w, "server_mem_load{server=%q} %v\n", s.Name, s.MemLoad)
+	}
+	fmt.Fprintln(w, "# HELP server_pulse_count Total pulses ever received for a server.")
+	fmt.Fprintln(w, "# TYPE server_pulse_count counter")
+	for _, s := range servers {
+		fmt.Fprintf(w, "server_pulse_count{server=%q} %d\n", s.Name, s.PulseCount)
+	}
+	fmt.Fprintln(w, "# HELP server_last_seen_seconds Seconds since the last pulse for a server.")
+	fmt.Fprintln(w, "# TYPE server_last_seen_seconds gauge")
+	for _, s := range servers {
+		fmt.Fprintf(w, "server_last_seen_seconds{server=%q} %v\n", s.Name, s.LastSeenSeconds)
+	}
+
+	fmt.Fprintln(w, "# HELP centurylinkchallenge_updates_received_total Total /update and /write points received.")
+	fmt.Fprintln(w, "# TYPE centurylinkchallenge_updates_received_total counter")
+	fmt.Fprintf(w, "centurylinkchallenge_updates_received_total %d\n", atomic.LoadUint64(&m.updatesReceived))
+
+	fmt.Fprintln(w, "# HELP centurylinkchallenge_updates_dropped_total Total updates rejected before being stored.")
+	fmt.Fprintln(w, "# TYPE centurylinkchallenge_updates_dropped_total counter")
+	fmt.Fprintf(w, "centurylinkchallenge_updates_dropped_total %d\n", atomic.LoadUint64(&m.updatesDropped))
+
+	fmt.Fprintln(w, "# HELP centurylinkchallenge_gets_served_total Total /get requests served.")
+	fmt.Fprintln(w, "# TYPE centurylinkchallenge_gets_served_total counter")
+	fmt.Fprintf(w, "centurylinkchallenge_gets_served_total %d\n", atomic.LoadUint64(&m.getsServed))
+
+	fmt.Fprintln(w, "# HELP centurylinkchallenge_decode_errors_total Total payloads that failed to decode.")
+	fmt.Fprintln(w, "# TYPE centurylinkchallenge_decode_errors_total counter")
+	fmt.Fprintf(w, "centurylinkchallenge_decode_errors_total %d\n", atomic.LoadUint64(&m.decodeErrors))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP centurylinkchallenge_requests_total Requests by path and status code.")
+	fmt.Fprintln(w, "# TYPE centurylinkchallenge_requests_total counter")
+	for rs, count := range m.statuses {
+		fmt.Fprintf(w, "centurylinkchallenge_requests_total{path=%q,status=\"%d\"} %d\n", rs.path, rs.status, count)
+	}
+
+	fmt.Fprintln(w, "# HELP centurylinkchallenge_request_duration_seconds Request latency by path.")
+	fmt.Fprintln(w, "# TYPE centurylinkchallenge_request_duration_seconds histogram")
+	for path, buckets := range m.latencyCounts {
+		for i, le := range latencyBuckets {
+			fmt.Fprintf(w, "centurylinkchallenge_request_duration_seconds_bucket{path=%q,le=\"%v\"} %d\n", path, le, buckets[i])
+		}
+		fmt.Fprintf(w, "centurylinkchallenge_request_duration_seconds_bucket{path=%q,le=\"+Inf\"} %d\n", path, m.latencyTotal[path])
+		fmt.Fprintf(w, "centurylinkchallenge_request_duration_seconds_sum{path=%q} %v\n", path, m.latencySum[path])
+		fmt.Fprintf(w, "centurylinkchallenge_request_duration_seconds_count{path=%q} %d\n", path, m.latencyTotal[path])
+	}
+}
+
+// ServerSnapshot is a point-in-time view of a single server's latest load and
+// pulse history, shared by /metrics and /stats.json.
+type ServerSnapshot struct {
+	Name            string  `json:"name"`
+	CpuLoad         float64 `json:"cpu_load"`
+	MemLoad         float64 `json:"mem_load"`
+	PulseCount      int     `json:"pulse_count"`
+	LastSeenSeconds float64 `json:"last_seen_seconds"`
+}
+
+// StatsSnapshot is the payload returned by /stats.json.
+type StatsSnapshot struct {
+	Servers         []ServerSnapshot `json:"servers"`
+	UpdatesReceived uint64           `json:"updates_received"`
+	UpdatesDropped  uint64           `json:"updates_dropped"`
+	GetsServed      uint64           `json:"gets_served"`
+	DecodeErrors    uint64           `json:"decode_errors"`
+}
+
+// metrics serves the current state in Prometheus text exposition format.
+func metrics(c *Context, w http.ResponseWriter, r *http.Request) (int, error) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	c.Metrics.writeProm(w, c.snapshotServers(time.Now()))
+	return http.StatusOK, nil
+}
+
+// statsJSON serves the same data as metrics, shaped for dashboards.
+func statsJSON(c *Context, w http.ResponseWriter, r *http.Request) (int, error) {
+	snap := StatsSnapshot{
+		Servers:         c.snapshotServers(time.Now()),
+		UpdatesReceived: atomic.LoadUint64(&c.Metrics.updatesReceived),
+		UpdatesDropped:  atomic.LoadUint64(&c.Metrics.updatesDropped),
+		GetsServed:      atomic.LoadUint64(&c.Metrics.getsServed),
+		DecodeErrors:    atomic.LoadUint64(&c.Metrics.decodeErrors),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// snapshotServers walks every known server and reports its most recent load.
+func (c *Context) snapshotServers(now time.Time) []ServerSnapshot {
+	var out []ServerSnapshot
+	c.store.Range(func(name string, stats *rollingStats) bool {
+		cpu, mem, lastSeen, count := stats.lastPulse()
+		out = append(out, ServerSnapshot{
+			Name:            name,
+			CpuLoad:         cpu,
+			MemLoad:         mem,
+			PulseCount:      count,
+			LastSeenSeconds: now.Sub(lastSeen).Seconds(),
+		})
+		return true
+	})
+	return out
+}
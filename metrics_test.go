@@ -0,0 +1,94 @@
+package centurylinkchallenge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMetricsExposesServerGaugesAndCounters(t *testing.T) {
+	c := NewContext(nil)
+	c.upsert(&pulse{Name: "web-1", Cpu: 12.5, Mem: 34.5})
+
+	// prime the latency histogram: a request's own timing can't appear in its
+	// own response body, so observe one request before scraping /metrics.
+	warmup := httptest.NewRequest("GET", "/metrics", nil)
+	Handler{c, metrics}.ServeHTTP(httptest.NewRecorder(), warmup)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	Handler{c, metrics}.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`server_cpu_load{server="web-1"} 12.5`,
+		`server_mem_load{server="web-1"} 34.5`,
+		`server_pulse_count{server="web-1"} 1`,
+		"centurylinkchallenge_updates_received_total",
+		"centurylinkchallenge_request_duration_seconds_bucket",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected /metrics output to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+// TestObserveLabelsByRouteTemplateNotPath guards against unbounded Prometheus
+// label cardinality: requests for distinct servers under /get/{server} must
+// all fold into the same "/get/{server}" series, not one series per server.
+func TestObserveLabelsByRouteTemplateNotPath(t *testing.T) {
+	c := NewContext(nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/get/{server}", Handler{c, get}.ServeHTTP)
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/get/server-%d", i), nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	Handler{c, metrics}.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if strings.Count(body, `path="/get/{server}"`) == 0 {
+		t.Fatalf("expected requests to be labeled with the route template, got:\n%s", body)
+	}
+	for i := 0; i < 5; i++ {
+		if strings.Contains(body, fmt.Sprintf(`path="/get/server-%d"`, i)) {
+			t.Fatalf("expected no per-server path label, but found one for server-%d:\n%s", i, body)
+		}
+	}
+}
+
+func TestStatsJSONReportsCountersAndServers(t *testing.T) {
+	c := NewContext(nil)
+	c.upsert(&pulse{Name: "web-1", Cpu: 1, Mem: 2})
+	c.Metrics.incUpdatesReceived()
+	c.Metrics.incGetsServed()
+
+	req := httptest.NewRequest("GET", "/stats.json", nil)
+	w := httptest.NewRecorder()
+	Handler{c, statsJSON}.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var snap StatsSnapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(snap.Servers) != 1 || snap.Servers[0].Name != "web-1" {
+		t.Fatalf("expected a single web-1 server snapshot, got %+v", snap.Servers)
+	}
+	if snap.UpdatesReceived != 1 || snap.GetsServed != 1 {
+		t.Fatalf("expected counters to reflect recorded activity, got %+v", snap)
+	}
+}
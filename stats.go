@@ -0,0 +1,282 @@
+package centurylinkchallenge
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// minuteBuckets and hourBuckets size the two rotating histograms kept per
+// server: the last 60 minutes broken down by minute, and the last 24 hours
+// broken down by hour.
+const (
+	minuteBuckets = 60
+	hourBuckets   = 24
+)
+
+// Stat is a pre-aggregated summary of a single bucket's worth of samples.
+// Count is 0 for a bucket that fell inside the window but never received a
+// sample, which is reported explicitly rather than being left out.
+type Stat struct {
+	Count  int     `json:"count"`
+	Avg    float64 `json:"avg"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	StdDev float64 `json:"stddev"`
+}
+
+// BucketStats pairs the CPU and memory summaries for one bucket.
+type BucketStats struct {
+	Cpu Stat `json:"cpu"`
+	Mem Stat `json:"mem"`
+}
+
+// bucket holds the running sums for one time slot. Everything needed for
+// count/avg/min/max/stddev is kept as a running total so a new pulse updates
+// the bucket in O(1) and nothing is ever recomputed by rescanning samples.
+type bucket struct {
+	start time.Time // the slot this bucket currently represents
+
+	count            int
+	cpuSum, cpuSumSq float64
+	cpuMin, cpuMax   float64
+	memSum, memSumSq float64
+	memMin, memMax   float64
+}
+
+// add folds a pulse into the bucket's running sums.
+func (b *bucket) add(p *pulse) {
+	if b.count == 0 {
+		b.cpuMin, b.cpuMax = p.Cpu, p.Cpu
+		b.memMin, b.memMax = p.Mem, p.Mem
+	} else {
+		b.cpuMin = math.Min(b.cpuMin, p.Cpu)
+		b.cpuMax = math.Max(b.cpuMax, p.Cpu)
+		b.memMin = math.Min(b.memMin, p.Mem)
+		b.memMax = math.Max(b.memMax, p.Mem)
+	}
+	b.count++
+	b.cpuSum += p.Cpu
+	b.cpuSumSq += p.Cpu * p.Cpu
+	b.memSum += p.Mem
+	b.memSumSq += p.Mem * p.Mem
+}
+
+// stats renders the bucket's running sums into the reported CPU/memory pair.
+func (b *bucket) stats() BucketStats {
+	return BucketStats{
+		Cpu: summarize(b.count, b.cpuSum, b.cpuSumSq, b.cpuMin, b.cpuMax),
+		Mem: summarize(b.count, b.memSum, b.memSumSq, b.memMin, b.memMax),
+	}
+}
+
+// summarize turns running sums into count/avg/min/max/stddev.
+func summarize(count int, sum, sumSq, min, max float64) Stat {
+	if count == 0 {
+		return Stat{}
+	}
+	avg := sum / float64(count)
+	// guard against floating point drift producing a tiny negative variance
+	variance := math.Max(0, sumSq/float64(count)-avg*avg)
+	return Stat{
+		Count:  count,
+		Avg:    avg,
+		Min:    min,
+		Max:    max,
+		StdDev: math.Sqrt(variance),
+	}
+}
+
+// bucketState is bucket's running sums in a form the wal Store can marshal
+// to JSON for a snapshot; bucket itself stays unexported and tag-free.
+type bucketState struct {
+	Start    time.Time
+	Count    int
+	CpuSum   float64
+	CpuSumSq float64
+	CpuMin   float64
+	CpuMax   float64
+	MemSum   float64
+	MemSumSq float64
+	MemMin   float64
+	MemMax   float64
+}
+
+func (b bucket) toState() bucketState {
+	return bucketState{
+		Start: b.start, Count: b.count,
+		CpuSum: b.cpuSum, CpuSumSq: b.cpuSumSq, CpuMin: b.cpuMin, CpuMax: b.cpuMax,
+		MemSum: b.memSum, MemSumSq: b.memSumSq, MemMin: b.memMin, MemMax: b.memMax,
+	}
+}
+
+func (s bucketState) toBucket() bucket {
+	return bucket{
+		start: s.Start, count: s.Count,
+		cpuSum: s.CpuSum, cpuSumSq: s.CpuSumSq, cpuMin: s.CpuMin, cpuMax: s.CpuMax,
+		memSum: s.MemSum, memSumSq: s.MemSumSq, memMin: s.MemMin, memMax: s.MemMax,
+	}
+}
+
+// rollingStatsState is a point-in-time snapshot of a rollingStats, used by
+// the wal Store to persist and restore its in-memory aggregates without
+// replaying every pulse that produced them.
+type rollingStatsState struct {
+	Seen    int
+	Last    pulse
+	Minutes [minuteBuckets]bucketState
+	Hours   [hourBuckets]bucketState
+}
+
+// state captures the current aggregates as a rollingStatsState.
+func (rs *rollingStats) state() rollingStatsState {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	var st rollingStatsState
+	st.Seen = rs.seen
+	st.Last = rs.last
+	for i, b := range rs.minutes {
+		st.Minutes[i] = b.toState()
+	}
+	for i, b := range rs.hours {
+		st.Hours[i] = b.toState()
+	}
+	return st
+}
+
+// restoreState replaces the current aggregates with a previously captured
+// rollingStatsState.
+func (rs *rollingStats) restoreState(st rollingStatsState) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.seen = st.Seen
+	rs.last = st.Last
+	for i, b := range st.Minutes {
+		rs.minutes[i] = b.toBucket()
+	}
+	for i, b := range st.Hours {
+		rs.hours[i] = b.toBucket()
+	}
+}
+
+// rollingStats is the per-server rolling statistics subsystem: 60 one-minute
+// buckets and 24 one-hour buckets, rotated forward lazily as time advances
+// rather than recomputed by scanning raw samples. A new pulse updates the
+// current bucket of each histogram in O(1); reading either histogram is
+// O(minuteBuckets+hourBuckets) regardless of how many pulses have ever been
+// received.
+type rollingStats struct {
+	mu      sync.Mutex
+	seen    int
+	last    pulse
+	minutes [minuteBuckets]bucket
+	hours   [hourBuckets]bucket
+}
+
+// insert folds a pulse into both histograms, rotating out any bucket whose
+// slot has aged out of its window first.
+func (rs *rollingStats) insert(p *pulse) {
+	rs.insertAt(p, time.Now())
+}
+
+// insertAt is insert with an explicit "now" for the rotation sweep, so tests
+// can exercise bucket aging deterministically.
+func (rs *rollingStats) insertAt(p *pulse, now time.Time) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.seen++
+	rs.last = *p
+	touch(rs.minutes[:], time.Minute, now, p.Time, p)
+	touch(rs.hours[:], time.Hour, now, p.Time, p)
+}
+
+// empty reports whether this server has ever received a pulse.
+func (rs *rollingStats) empty() bool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.seen == 0
+}
+
+// last reports the most recently received pulse's CPU/memory load and
+// timestamp, plus the total number of pulses ever seen.
+func (rs *rollingStats) lastPulse() (cpu, mem float64, at time.Time, count int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.last.Cpu, rs.last.Mem, rs.last.Time, rs.seen
+}
+
+// minuteSnapshot returns the 60 one-minute buckets ordered oldest-to-newest,
+// relative to now.
+func (rs *rollingStats) minuteSnapshot(now time.Time) [minuteBuckets]BucketStats {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rotate(rs.minutes[:], time.Minute, now)
+	return snapshot60(rs.minutes, time.Minute, now)
+}
+
+// hourSnapshot returns the 24 one-hour buckets ordered oldest-to-newest,
+// relative to now.
+func (rs *rollingStats) hourSnapshot(now time.Time) [hourBuckets]BucketStats {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rotate(rs.hours[:], time.Hour, now)
+	return snapshot24(rs.hours, time.Hour, now)
+}
+
+// touch clears a stale bucket for the slot t falls into, then folds p in.
+// The staleness sweep is driven by now, the real wall clock, rather than by
+// t, the pulse's own (client-supplied, unvalidated) timestamp: otherwise a
+// single sample with a badly skewed timestamp would evict every other
+// legitimate bucket in the array instead of just its own slot.
+func touch(buckets []bucket, rate time.Duration, now, t time.Time, p *pulse) {
+	rotate(buckets, rate, now)
+	idx := slotIndex(t, rate, len(buckets))
+	b := &buckets[idx]
+	slot := t.Truncate(rate)
+	if !b.start.Equal(slot) {
+		*b = bucket{start: slot}
+	}
+	b.add(p)
+}
+
+// rotate zeroes out any bucket whose slot has fallen outside the window
+// [now-len(buckets)*rate, now), so gaps are reported as zero-count buckets
+// instead of silently holding stale data or being skipped.
+func rotate(buckets []bucket, rate time.Duration, now time.Time) {
+	window := rate * time.Duration(len(buckets))
+	for i := range buckets {
+		if buckets[i].count > 0 && now.Sub(buckets[i].start) >= window {
+			buckets[i] = bucket{}
+		}
+	}
+}
+
+// slotIndex maps a time to its ring position for the given bucket rate.
+func slotIndex(t time.Time, rate time.Duration, size int) int {
+	return int((t.Unix() / int64(rate.Seconds())) % int64(size))
+}
+
+// snapshot60 and snapshot24 order a ring buffer's contents oldest-to-newest.
+// They're generated rather than generic since the API reports fixed-size
+// 60/24 arrays.
+func snapshot60(buckets [minuteBuckets]bucket, rate time.Duration, now time.Time) [minuteBuckets]BucketStats {
+	var out [minuteBuckets]BucketStats
+	cur := slotIndex(now, rate, minuteBuckets)
+	for k := 0; k < minuteBuckets; k++ {
+		back := minuteBuckets - 1 - k
+		idx := ((cur-back)%minuteBuckets + minuteBuckets) % minuteBuckets
+		out[k] = buckets[idx].stats()
+	}
+	return out
+}
+
+func snapshot24(buckets [hourBuckets]bucket, rate time.Duration, now time.Time) [hourBuckets]BucketStats {
+	var out [hourBuckets]BucketStats
+	cur := slotIndex(now, rate, hourBuckets)
+	for k := 0; k < hourBuckets; k++ {
+		back := hourBuckets - 1 - k
+		idx := ((cur-back)%hourBuckets + hourBuckets) % hourBuckets
+		out[k] = buckets[idx].stats()
+	}
+	return out
+}
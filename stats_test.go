@@ -0,0 +1,99 @@
+package centurylinkchallenge
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRollingStatsMinuteBucketAggregates(t *testing.T) {
+	rs := &rollingStats{}
+	base := time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	rs.insertAt(&pulse{Cpu: 10, Mem: 20, Time: base}, base)
+	rs.insertAt(&pulse{Cpu: 30, Mem: 40, Time: base.Add(10 * time.Second)}, base.Add(10*time.Second))
+
+	snap := rs.minuteSnapshot(base.Add(10 * time.Second))
+	current := snap[minuteBuckets-1].Cpu
+
+	if current.Count != 2 {
+		t.Fatalf("expected count 2, got %d", current.Count)
+	}
+	if current.Min != 10 || current.Max != 30 {
+		t.Fatalf("expected min/max 10/30, got %v/%v", current.Min, current.Max)
+	}
+	if current.Avg != 20 {
+		t.Fatalf("expected avg 20, got %v", current.Avg)
+	}
+	wantStdDev := math.Sqrt(100) // population stddev of {10,30} is 10
+	if math.Abs(current.StdDev-wantStdDev) > 1e-9 {
+		t.Fatalf("expected stddev %v, got %v", wantStdDev, current.StdDev)
+	}
+}
+
+func TestRollingStatsReportsZeroCountGaps(t *testing.T) {
+	rs := &rollingStats{}
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	rs.insertAt(&pulse{Cpu: 5, Mem: 5, Time: base}, base)
+
+	// jump forward 3 minutes with no pulses in between
+	snap := rs.minuteSnapshot(base.Add(3 * time.Minute))
+
+	if snap[minuteBuckets-1].Cpu.Count != 0 {
+		t.Fatalf("expected the most recent minute to be an empty gap, got %+v", snap[minuteBuckets-1])
+	}
+	found := false
+	for _, b := range snap {
+		if b.Cpu.Count == 1 && b.Cpu.Avg == 5 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected the original sample to still be present in the window, got %+v", snap)
+	}
+}
+
+func TestRollingStatsDropsBucketsOutsideWindow(t *testing.T) {
+	rs := &rollingStats{}
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	rs.insertAt(&pulse{Cpu: 99, Mem: 99, Time: base}, base)
+
+	// well past the 60 minute window: the old sample must not reappear
+	snap := rs.minuteSnapshot(base.Add(2 * time.Hour))
+	for _, b := range snap {
+		if b.Cpu.Count != 0 {
+			t.Fatalf("expected all buckets empty after the sample aged out, got %+v", b)
+		}
+	}
+}
+
+// TestRollingStatsInsertIgnoresPulseTimeForRotation guards against a pulse
+// with a badly skewed timestamp (bad client clock, or a malformed/adversarial
+// request body) evicting every other legitimate bucket: the rotation sweep
+// must be driven by the real wall clock passed to insertAt, not by the
+// pulse's own Time field.
+func TestRollingStatsInsertIgnoresPulseTimeForRotation(t *testing.T) {
+	rs := &rollingStats{}
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 10; i++ {
+		at := base.Add(time.Duration(i) * time.Minute)
+		rs.insertAt(&pulse{Cpu: 1, Mem: 1, Time: at}, at)
+	}
+
+	// a pulse claiming to be 5 hours in the future must not be trusted as
+	// "now" for the staleness sweep; wall-clock time has barely advanced.
+	skewed := base.Add(5 * time.Hour)
+	rs.insertAt(&pulse{Cpu: 99, Mem: 99, Time: skewed}, base.Add(9*time.Minute+1*time.Second))
+
+	snap := rs.minuteSnapshot(base.Add(9 * time.Minute))
+	populated := 0
+	for _, b := range snap {
+		if b.Cpu.Count > 0 {
+			populated++
+		}
+	}
+	if populated < 9 {
+		t.Fatalf("expected the earlier buckets to survive a pulse with a skewed future timestamp, got %d populated buckets", populated)
+	}
+}
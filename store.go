@@ -0,0 +1,67 @@
+package centurylinkchallenge
+
+import "sync"
+
+// Store is the persistence backend behind a Context. Every pulse flows
+// through Append, and /get and /metrics read server state back out through
+// LoadServer and Range. Implementations decide how, or whether, that state
+// survives a restart.
+type Store interface {
+	// Append durably records a pulse and folds it into that server's
+	// rolling statistics.
+	Append(p *pulse) error
+	// LoadServer returns a server's rolling statistics, if it has ever
+	// pulsed.
+	LoadServer(name string) (*rollingStats, bool)
+	// Range calls fn for every known server. Iteration stops early if fn
+	// returns false.
+	Range(fn func(name string, stats *rollingStats) bool)
+	// Snapshot persists the current state, so a future restart can replay
+	// from this point instead of from the beginning of the log.
+	Snapshot() error
+	// Replay feeds every pulse recorded since the last snapshot to fn, in
+	// the order it was appended, so a fresh Context can rebuild in-memory
+	// state after a restart.
+	Replay(fn func(*pulse)) error
+	// Close flushes and releases any resources the store holds.
+	Close() error
+}
+
+// memoryStore is the original, non-durable Store: state lives only in
+// process memory and is lost on restart. servers is a sync.Map of string ->
+// *rollingStats so that lookups and inserts for different servers never
+// contend with one another; each rollingStats in turn guards itself, giving
+// per-server striped locking rather than one global lock.
+type memoryStore struct {
+	servers sync.Map
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Append(p *pulse) error {
+	v, _ := s.servers.LoadOrStore(p.Name, &rollingStats{})
+	v.(*rollingStats).insert(p)
+	return nil
+}
+
+func (s *memoryStore) LoadServer(name string) (*rollingStats, bool) {
+	v, ok := s.servers.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*rollingStats), true
+}
+
+func (s *memoryStore) Range(fn func(name string, stats *rollingStats) bool) {
+	s.servers.Range(func(k, v interface{}) bool {
+		return fn(k.(string), v.(*rollingStats))
+	})
+}
+
+// Snapshot and Replay are no-ops: there is nothing on disk to persist to or
+// rebuild from.
+func (s *memoryStore) Snapshot() error              { return nil }
+func (s *memoryStore) Replay(fn func(*pulse)) error { return nil }
+func (s *memoryStore) Close() error                 { return nil }
@@ -0,0 +1,384 @@
+package centurylinkchallenge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy controls how aggressively a walStore flushes appended pulses
+// to disk.
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"   // fsync after every append
+	FsyncInterval FsyncPolicy = "interval" // fsync on a timer
+	FsyncNever    FsyncPolicy = "never"    // rely on the OS page cache
+)
+
+const snapshotFileName = "snapshot.json"
+
+// WALConfig configures a durable, write-ahead-logged Store.
+type WALConfig struct {
+	Dir             string        // directory holding WAL segments and the snapshot
+	Retention       time.Duration // WAL segments entirely older than this are dropped
+	Fsync           FsyncPolicy
+	FsyncInterval   time.Duration // used when Fsync == FsyncInterval
+	CompactInterval time.Duration // how often the compaction goroutine runs
+}
+
+// withDefaults fills in the same defaults a service deployed with minimal
+// configuration would want: a full 24h of retention to match the rolling
+// windows Context reports, fsync on a 1s timer rather than every write, and
+// compaction every 5 minutes.
+func (cfg WALConfig) withDefaults() WALConfig {
+	if cfg.Retention <= 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	if cfg.Fsync == "" {
+		cfg.Fsync = FsyncInterval
+	}
+	if cfg.FsyncInterval <= 0 {
+		cfg.FsyncInterval = time.Second
+	}
+	if cfg.CompactInterval <= 0 {
+		cfg.CompactInterval = 5 * time.Minute
+	}
+	return cfg
+}
+
+// walStore is a Store backed by an append-only write-ahead log plus periodic
+// snapshots of the in-memory rolling aggregates, so a restart replays only
+// the WAL segments written since the last snapshot instead of losing the
+// last 24h of data.
+type walStore struct {
+	cfg WALConfig
+
+	mu      sync.Mutex
+	segment *os.File
+	enc     *json.Encoder
+
+	servers sync.Map // name -> *rollingStats
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// newWALStore opens (creating if needed) the WAL directory, loads the most
+// recent snapshot and replays any WAL segments written after it to rebuild
+// in-memory state, opens a fresh segment for new writes, and starts the
+// background compactor.
+func newWALStore(cfg WALConfig) (*walStore, error) {
+	cfg = cfg.withDefaults()
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("wal store requires a directory")
+	}
+	switch cfg.Fsync {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("unrecognized fsync policy %q: must be %q, %q, or %q", cfg.Fsync, FsyncAlways, FsyncInterval, FsyncNever)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating WAL directory: %w", err)
+	}
+
+	s := &walStore{cfg: cfg, stop: make(chan struct{})}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.Replay(func(p *pulse) {
+		v, _ := s.servers.LoadOrStore(p.Name, &rollingStats{})
+		v.(*rollingStats).insert(p)
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.openSegment(); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.compactLoop()
+	if cfg.Fsync == FsyncInterval {
+		s.wg.Add(1)
+		go s.fsyncLoop()
+	}
+	return s, nil
+}
+
+// Append writes p to the current WAL segment and folds it into the server's
+// in-memory rolling statistics.
+func (s *walStore) Append(p *pulse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(p); err != nil {
+		return fmt.Errorf("appending to WAL: %w", err)
+	}
+	if s.cfg.Fsync == FsyncAlways {
+		if err := s.segment.Sync(); err != nil {
+			return fmt.Errorf("fsyncing WAL: %w", err)
+		}
+	}
+	v, _ := s.servers.LoadOrStore(p.Name, &rollingStats{})
+	v.(*rollingStats).insert(p)
+	return nil
+}
+
+func (s *walStore) LoadServer(name string) (*rollingStats, bool) {
+	v, ok := s.servers.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*rollingStats), true
+}
+
+func (s *walStore) Range(fn func(name string, stats *rollingStats) bool) {
+	s.servers.Range(func(k, v interface{}) bool {
+		return fn(k.(string), v.(*rollingStats))
+	})
+}
+
+// Snapshot persists the current aggregates to snapshot.json, then rotates to
+// a fresh WAL segment and removes every segment that preceded it: their
+// contents are now fully captured by the snapshot.
+func (s *walStore) Snapshot() error {
+	// s.mu is held for the entire build-snapshot-then-rotate sequence, not
+	// just the rotate/delete tail: otherwise an Append landing between the
+	// snap being built and the old segment being deleted writes a pulse that
+	// is in neither the snapshot nor any surviving segment, silently losing
+	// an acknowledged write.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := make(map[string]rollingStatsState)
+	s.servers.Range(func(k, v interface{}) bool {
+		snap[k.(string)] = v.(*rollingStats).state()
+		return true
+	})
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	path := filepath.Join(s.cfg.Dir, snapshotFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("committing snapshot: %w", err)
+	}
+
+	stale := s.segment
+	stalePath := stale.Name()
+	if err := s.openSegment(); err != nil {
+		return err
+	}
+	stale.Close()
+
+	segments, err := s.segmentPaths()
+	if err != nil {
+		return err
+	}
+	for _, p := range segments {
+		if p == s.segment.Name() {
+			continue
+		}
+		os.Remove(p)
+	}
+	os.Remove(stalePath)
+	return nil
+}
+
+// Replay feeds every pulse recorded in WAL segments on disk to fn, oldest
+// segment first. It's called once at startup, after loadSnapshot, so it only
+// ever needs to cover segments written since the last snapshot.
+func (s *walStore) Replay(fn func(*pulse)) error {
+	segments, err := s.segmentPaths()
+	if err != nil {
+		return err
+	}
+	for _, path := range segments {
+		if err := replaySegment(path, fn); err != nil {
+			return fmt.Errorf("replaying %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func replaySegment(path string, fn func(*pulse)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for {
+		var p pulse
+		if err := dec.Decode(&p); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		fn(&p)
+	}
+}
+
+// Close stops the background goroutines and closes the current segment.
+func (s *walStore) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+		s.mu.Lock()
+		err = s.segment.Close()
+		s.mu.Unlock()
+	})
+	return err
+}
+
+// loadSnapshot restores any previously persisted aggregates before Replay
+// folds in whatever WAL segments were written after that snapshot was taken.
+func (s *walStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.cfg.Dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	var snap map[string]rollingStatsState
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decoding snapshot: %w", err)
+	}
+	for name, st := range snap {
+		rs := &rollingStats{}
+		rs.restoreState(st)
+		s.servers.Store(name, rs)
+	}
+	return nil
+}
+
+// openSegment starts a new WAL segment named for the moment it was opened,
+// so segments sort chronologically by name. Callers must hold s.mu.
+func (s *walStore) openSegment() error {
+	name := fmt.Sprintf("wal-%d.log", time.Now().UnixNano())
+	f, err := os.OpenFile(filepath.Join(s.cfg.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening WAL segment: %w", err)
+	}
+	s.segment = f
+	s.enc = json.NewEncoder(f)
+	return nil
+}
+
+// segmentPaths lists every *.log segment in the WAL directory, oldest first.
+func (s *walStore) segmentPaths() ([]string, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".log") {
+			paths = append(paths, filepath.Join(s.cfg.Dir, e.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// segmentTimestamp recovers the creation time encoded in a segment's name.
+func segmentTimestamp(path string) (time.Time, bool) {
+	base := strings.TrimSuffix(filepath.Base(path), ".log")
+	base = strings.TrimPrefix(base, "wal-")
+	n, err := strconv.ParseInt(base, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, n), true
+}
+
+// pruneOldSegments is a safety net independent of snapshotting: it bounds
+// disk usage by the configured retention even if compaction has fallen
+// behind or Snapshot has been failing.
+func (s *walStore) pruneOldSegments() {
+	cutoff := time.Now().Add(-s.cfg.Retention)
+	segments, err := s.segmentPaths()
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	current := s.segment.Name()
+	s.mu.Unlock()
+	for _, p := range segments {
+		if p == current {
+			continue
+		}
+		if ts, ok := segmentTimestamp(p); ok && ts.Before(cutoff) {
+			os.Remove(p)
+		}
+	}
+}
+
+// compactLoop periodically snapshots the current aggregates and trims stale
+// WAL segments, so a restart never has to replay more than one compaction
+// interval's worth of writes.
+func (s *walStore) compactLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.CompactInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				log.Println(err)
+			}
+			s.pruneOldSegments()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// fsyncLoop periodically flushes the current segment when Fsync is
+// FsyncInterval, trading a small durability window for not paying an fsync
+// on every single append.
+func (s *walStore) fsyncLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.FsyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			s.segment.Sync()
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// newStoreFromEnv picks the Store StartTheChallenge wires into its Context.
+// Setting CLC_WAL_DIR opts into the durable walStore; otherwise the original
+// non-durable memoryStore is used, matching prior behavior. CLC_FSYNC_POLICY
+// selects the walStore's fsync policy when set.
+func newStoreFromEnv() (Store, error) {
+	dir := os.Getenv("CLC_WAL_DIR")
+	if dir == "" {
+		return newMemoryStore(), nil
+	}
+	return newWALStore(WALConfig{
+		Dir:   dir,
+		Fsync: FsyncPolicy(os.Getenv("CLC_FSYNC_POLICY")),
+	})
+}
@@ -0,0 +1,153 @@
+package centurylinkchallenge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWALStoreSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	s1, err := newWALStore(WALConfig{Dir: dir, Fsync: FsyncAlways})
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	if err := s1.Append(&pulse{Name: "web-1", Cpu: 10, Mem: 20, Time: time.Now()}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := s1.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	s2, err := newWALStore(WALConfig{Dir: dir, Fsync: FsyncAlways})
+	if err != nil {
+		t.Fatalf("reopening store: %v", err)
+	}
+	defer s2.Close()
+
+	stats, ok := s2.LoadServer("web-1")
+	if !ok {
+		t.Fatal("expected web-1 to survive a restart")
+	}
+	cpu, mem, _, count := stats.lastPulse()
+	if count != 1 || cpu != 10 || mem != 20 {
+		t.Fatalf("expected one replayed pulse with cpu=10 mem=20, got count=%d cpu=%v mem=%v", count, cpu, mem)
+	}
+}
+
+func TestWALStoreSnapshotRotatesAndPrunesSegments(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := newWALStore(WALConfig{Dir: dir, Fsync: FsyncAlways})
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Append(&pulse{Name: "web-1", Cpu: 1, Mem: 2, Time: time.Now()}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := s.Snapshot(); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+		t.Fatalf("expected snapshot.json to exist: %v", err)
+	}
+	segments, err := s.segmentPaths()
+	if err != nil {
+		t.Fatalf("listing segments: %v", err)
+	}
+	if len(segments) != 1 {
+		t.Fatalf("expected exactly one segment to remain after a snapshot, got %v", segments)
+	}
+
+	// the pulse appended before the snapshot must still be reflected.
+	stats, ok := s.LoadServer("web-1")
+	if !ok || stats.empty() {
+		t.Fatal("expected web-1's stats to survive the snapshot rotation")
+	}
+}
+
+// TestWALStoreSnapshotDoesNotLoseConcurrentAppend guards against a pulse
+// landing in the window between the snapshot being built and the old
+// segment being deleted: such a pulse must end up in either the snapshot or
+// a surviving segment, never neither.
+func TestWALStoreSnapshotDoesNotLoseConcurrentAppend(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := newWALStore(WALConfig{Dir: dir, Fsync: FsyncAlways})
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			s.Append(&pulse{Name: "web-1", Cpu: float64(i), Mem: float64(i), Time: time.Now()})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		if err := s.Snapshot(); err != nil {
+			t.Fatalf("snapshot: %v", err)
+		}
+	}
+	<-done
+	if err := s.Snapshot(); err != nil {
+		t.Fatalf("final snapshot: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	s2, err := newWALStore(WALConfig{Dir: dir, Fsync: FsyncAlways})
+	if err != nil {
+		t.Fatalf("reopening store: %v", err)
+	}
+	defer s2.Close()
+
+	stats, ok := s2.LoadServer("web-1")
+	if !ok {
+		t.Fatal("expected web-1 to survive a restart")
+	}
+	if _, _, _, count := stats.lastPulse(); count != 50 {
+		t.Fatalf("expected all 50 concurrently appended pulses to survive, got %d", count)
+	}
+}
+
+// TestNewWALStoreRejectsUnrecognizedFsyncPolicy guards against a typo'd
+// CLC_FSYNC_POLICY silently degrading durability: an unrecognized value must
+// fail fast at startup rather than falling through every == comparison and
+// behaving like FsyncNever with no error or log.
+func TestNewWALStoreRejectsUnrecognizedFsyncPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := newWALStore(WALConfig{Dir: dir, Fsync: FsyncPolicy("alway")})
+	if err == nil {
+		t.Fatal("expected an error for an unrecognized fsync policy")
+	}
+}
+
+func TestWALStorePrunesSegmentsOlderThanRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := newWALStore(WALConfig{Dir: dir, Fsync: FsyncAlways, Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	defer s.Close()
+
+	stale := filepath.Join(dir, "wal-1.log")
+	if err := os.WriteFile(stale, []byte{}, 0o644); err != nil {
+		t.Fatalf("writing stale segment: %v", err)
+	}
+
+	s.pruneOldSegments()
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected stale segment to be pruned, stat err: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package centurylinkchallenge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/morganhein/centurylinkchallenge/lineprotocol"
+)
+
+// write accepts a batch of InfluxDB line protocol points and folds each one
+// in as a pulse, so existing Telegraf/collectd/vector agents can push into
+// this service without a custom JSON shim. The "host" tag maps to the
+// pulse's server name; "cpu" and "mem" fields map to its load values.
+func write(c *Context, w http.ResponseWriter, r *http.Request) (int, error) {
+	precision := lineprotocol.Precision(r.URL.Query().Get("precision"))
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println(err)
+		return http.StatusInternalServerError, err
+	}
+
+	points, err := lineprotocol.Parse(body, precision)
+	if err != nil {
+		log.Println(err)
+		c.Metrics.incDecodeErrors()
+		c.Metrics.incUpdatesDropped()
+		return http.StatusBadRequest, err
+	}
+
+	// Translate every point to a pulse before storing any of them: a point
+	// later in the batch failing semantic validation (missing "host" tag,
+	// non-numeric field) must not leave earlier points in the same request
+	// already persisted when the whole request reports 400.
+	pulses := make([]*pulse, len(points))
+	for i, p := range points {
+		stat, err := pulseFromPoint(p)
+		if err != nil {
+			log.Println(err)
+			c.Metrics.incUpdatesDropped()
+			return http.StatusBadRequest, err
+		}
+		pulses[i] = stat
+	}
+
+	for _, stat := range pulses {
+		c.Metrics.incUpdatesReceived()
+		if _, err := c.upsert(stat); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	log.Printf("Received %d line protocol point(s) via /write", len(points))
+	return http.StatusOK, nil
+}
+
+// pulseFromPoint translates a decoded line protocol point into a pulse.
+func pulseFromPoint(p lineprotocol.Point) (*pulse, error) {
+	name, ok := p.Tags["host"]
+	if !ok {
+		return nil, fmt.Errorf("line protocol point for measurement %q is missing the \"host\" tag", p.Measurement)
+	}
+
+	t := p.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	stat := &pulse{Name: name, Time: t}
+
+	if v, ok := p.Fields["cpu"]; ok {
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("field \"cpu\" for host %q is not numeric: %v", name, v)
+		}
+		stat.Cpu = f
+	}
+	if v, ok := p.Fields["mem"]; ok {
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("field \"mem\" for host %q is not numeric: %v", name, v)
+		}
+		stat.Mem = f
+	}
+	return stat, nil
+}
+
+// toFloat widens the numeric field value types the parser produces.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
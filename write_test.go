@@ -0,0 +1,83 @@
+package centurylinkchallenge
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteIngestsLineProtocolBatch(t *testing.T) {
+	c := NewContext(nil)
+	handler := Handler{c, write}
+
+	body := "cpu,host=web-1 cpu=10.5,mem=20.5 100\ncpu,host=web-2 cpu=30.5,mem=40.5 200"
+	req := httptest.NewRequest("POST", "/write", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	stats, exists := c.store.LoadServer("web-1")
+	if !exists {
+		t.Fatal("expected server web-1 to have been recorded")
+	}
+	if stats.empty() {
+		t.Fatal("expected web-1 to have received a pulse")
+	}
+}
+
+func TestWriteRejectsMalformedLineWithLineNumber(t *testing.T) {
+	c := NewContext(nil)
+	handler := Handler{c, write}
+
+	body := "cpu,host=web-1 cpu=10.5 100\ncpu,host=web-2 cpu=notanumber 200"
+	req := httptest.NewRequest("POST", "/write", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != 400 {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "line 2") {
+		t.Fatalf("expected response to identify line 2, got %q", w.Body.String())
+	}
+}
+
+// TestWriteRejectsBatchAtomically guards against a batch being left
+// half-applied: a point failing semantic validation (here, a missing "host"
+// tag) must cause the whole request to be rejected with nothing from the
+// batch stored, even when earlier points in the same batch were valid.
+func TestWriteRejectsBatchAtomically(t *testing.T) {
+	c := NewContext(nil)
+	handler := Handler{c, write}
+
+	body := "cpu,host=web-1 cpu=10.5,mem=20.5 100\ncpu,host=web-2 cpu=30.5,mem=40.5 200\ncpu cpu=50.5,mem=60.5 300"
+	req := httptest.NewRequest("POST", "/write", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != 400 {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, exists := c.store.LoadServer("web-1"); exists {
+		t.Fatal("expected web-1 not to have been stored when a later point in the same batch was invalid")
+	}
+	if _, exists := c.store.LoadServer("web-2"); exists {
+		t.Fatal("expected web-2 not to have been stored when a later point in the same batch was invalid")
+	}
+}
+
+func TestWriteRejectsMissingHostTag(t *testing.T) {
+	c := NewContext(nil)
+	handler := Handler{c, write}
+
+	req := httptest.NewRequest("POST", "/write", strings.NewReader("cpu cpu=10.5 100"))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != 400 {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}